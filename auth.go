@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/time/rate"
+)
+
+var (
+	authFile = flag.String("auth-file", "", "Path to a JSON credentials file of per-device tokens (replaces --token)")
+	rateFlag = flag.Int("rate", 60, "Per-device rate limit, in requests per minute")
+)
+
+const scopeAdmin = "admin"
+
+// argon2 parameters, chosen per the RFC 9106 low-memory recommendation since
+// this runs alongside a desktop session rather than on dedicated hardware.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// deviceCred is one entry in the --auth-file credentials table.
+type deviceCred struct {
+	Name         string   `json:"name"`
+	TokenHash    string   `json:"token_hash"`
+	Scopes       []string `json:"scopes"`
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+}
+
+func (d *deviceCred) hasScope(scope string) bool {
+	for _, s := range d.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *deviceCred) allowsIP(ip net.IP) bool {
+	if len(d.AllowedCIDRs) == 0 {
+		return true
+	}
+	for _, cidr := range d.AllowedCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialStore holds the live, hot-reloadable set of device credentials
+// loaded from --auth-file, plus a per-device rate limiter table.
+type credentialStore struct {
+	mu         sync.RWMutex
+	path       string
+	devices    []deviceCred
+	limiters   sync.Map // map[string]*rate.Limiter, keyed by device name
+	ratePerMin int
+}
+
+func newCredentialStore(path string, ratePerMin int) (*credentialStore, error) {
+	cs := &credentialStore{path: path, ratePerMin: ratePerMin}
+	if path == "" {
+		return cs, nil
+	}
+	if err := cs.reload(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// reload re-reads the credentials file from disk, replacing the in-memory
+// table. Called at startup and on SIGHUP.
+func (cs *credentialStore) reload() error {
+	if cs.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(cs.path)
+	if err != nil {
+		return fmt.Errorf("failed to read auth file: %w", err)
+	}
+
+	var devices []deviceCred
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return fmt.Errorf("failed to parse auth file: %w", err)
+	}
+
+	cs.mu.Lock()
+	cs.devices = devices
+	cs.mu.Unlock()
+	return nil
+}
+
+// persist writes the current credentials table back to --auth-file.
+func (cs *credentialStore) persist() error {
+	cs.mu.RLock()
+	data, err := json.MarshalIndent(cs.devices, "", "  ")
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cs.path, data, 0600)
+}
+
+// authenticate matches the bearer token on r against the credentials table
+// and returns the device it belongs to, enforcing that device's
+// allowed_cidrs, if any. If no --auth-file is configured, every request is
+// treated as authenticated under an empty device name.
+func (cs *credentialStore) authenticate(r *http.Request) (deviceCred, bool) {
+	if cs.path == "" {
+		return deviceCred{}, true
+	}
+
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return deviceCred{}, false
+	}
+
+	cs.mu.RLock()
+	devices := cs.devices
+	cs.mu.RUnlock()
+
+	for _, d := range devices {
+		if !verifyToken(token, d.TokenHash) {
+			continue
+		}
+		ip := net.ParseIP(getClientIP(r))
+		if ip != nil && !d.allowsIP(ip) {
+			return deviceCred{}, false
+		}
+		return d, true
+	}
+	return deviceCred{}, false
+}
+
+// allow reports whether device is within its rate limit, creating a limiter
+// for it on first use.
+func (cs *credentialStore) allow(device string) bool {
+	limiterAny, _ := cs.limiters.LoadOrStore(device, rate.NewLimiter(rate.Limit(float64(cs.ratePerMin)/60), cs.ratePerMin))
+	limiter := limiterAny.(*rate.Limiter)
+	return limiter.Allow()
+}
+
+// addDevice generates a new token for name, stores its hash, and persists
+// the table. Returns the plaintext token, shown to the caller exactly once.
+func (cs *credentialStore) addDevice(name string, scopes, allowedCIDRs []string) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	hash, err := hashToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	cs.mu.Lock()
+	cs.devices = append(cs.devices, deviceCred{
+		Name:         name,
+		TokenHash:    hash,
+		Scopes:       scopes,
+		AllowedCIDRs: allowedCIDRs,
+	})
+	cs.mu.Unlock()
+
+	if err := cs.persist(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revokeDevice removes name from the credentials table and persists it.
+func (cs *credentialStore) revokeDevice(name string) (bool, error) {
+	cs.mu.Lock()
+	found := false
+	// Build a fresh slice rather than reslicing cs.devices[:0]: authenticate
+	// copies the slice header under RLock and then ranges it after
+	// releasing the lock, so writing into the old backing array here would
+	// race with that read.
+	var kept []deviceCred
+	for _, d := range cs.devices {
+		if d.Name == name {
+			found = true
+			continue
+		}
+		kept = append(kept, d)
+	}
+	cs.devices = kept
+	cs.mu.Unlock()
+
+	if !found {
+		return false, nil
+	}
+	return true, cs.persist()
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken derives an argon2id hash of token, encoded as
+// "salt-b64$hash-b64" so it can be stored in the credentials file.
+func hashToken(token string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	hash := argon2.IDKey([]byte(token), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(hash), nil
+}
+
+// verifyToken checks token against an encoded hash produced by hashToken.
+func verifyToken(token, encoded string) bool {
+	parts := strings.SplitN(encoded, "$", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	got := argon2.IDKey([]byte(token), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// auth is the process-wide credential store, set up in main().
+var auth *credentialStore
+
+type deviceCtxKeyType struct{}
+
+var deviceCtxKey deviceCtxKeyType
+
+// deviceFromContext returns the device name tagged onto r by withAudit, or
+// "" if the request wasn't authenticated against a device (e.g. no
+// --auth-file configured).
+func deviceFromContext(r *http.Request) string {
+	d, _ := r.Context().Value(deviceCtxKey).(deviceCred)
+	return d.Name
+}
+
+// deviceCredFromContext returns the full deviceCred tagged onto r by
+// withAudit, for handlers (like /admin/tokens) that need to check scopes.
+func deviceCredFromContext(r *http.Request) deviceCred {
+	d, _ := r.Context().Value(deviceCtxKey).(deviceCred)
+	return d
+}
+
+// adminTokensRequest is the body accepted by POST /admin/tokens.
+type adminTokensRequest struct {
+	Action       string   `json:"action"` // "add" or "revoke"
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+}
+
+// handleAdminTokens lets the primary user issue or revoke device tokens
+// without restarting the server. Requires the "admin" scope.
+func handleAdminTokens(w http.ResponseWriter, r *http.Request) {
+	device := deviceCredFromContext(r)
+	if !device.hasScope(scopeAdmin) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req adminTokensRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "Missing device name", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Action {
+	case "add":
+		token, err := auth.addDevice(req.Name, req.Scopes, req.AllowedCIDRs)
+		if err != nil {
+			logf("Failed to add device token for %s: %v\n", req.Name, err)
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"name": req.Name, "token": token})
+	case "revoke":
+		found, err := auth.revokeDevice(req.Name)
+		if err != nil {
+			logf("Failed to revoke device token for %s: %v\n", req.Name, err)
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Unknown action, expected add or revoke", http.StatusBadRequest)
+	}
+}
+
+// auditEntry is one line of the structured JSONL audit log.
+type auditEntry struct {
+	TS        string `json:"ts"`
+	Device    string `json:"device"`
+	IP        string `json:"ip"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	Bytes     int    `json:"bytes"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// auditLogger writes structured, machine-readable request records separate
+// from the free-form operational log.
+var auditLogger *log.Logger
+
+// statusRecorder wraps a ResponseWriter to capture the status code and byte
+// count written, for audit logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Hijack lets the /sync WebSocket upgrade take over the connection through
+// the recorder, since websocket.Upgrader requires an http.Hijacker.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+// withAudit wraps a handler to enforce the --local-only LAN/tailnet gate,
+// authenticate the request against the device credential table, enforce its
+// rate limit, tag the request context with the device name, and write a
+// JSONL audit record once it completes. CORS preflight (OPTIONS) requests
+// pass through untouched.
+func withAudit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		if !requestAllowed(r) {
+			logf("Rejected non-local request from %s\n", getClientIP(r))
+			http.Error(rec, "This service is restricted to local network use only", http.StatusForbidden)
+			writeAuditEntry(rec, r, "", start)
+			return
+		}
+
+		device, ok := auth.authenticate(r)
+		if !ok {
+			logf("Unauthorized access attempt from %s\n", getClientIP(r))
+			http.Error(rec, "Unauthorized", http.StatusUnauthorized)
+			writeAuditEntry(rec, r, "", start)
+			return
+		}
+		if auth.path != "" && !auth.allow(device.Name) {
+			http.Error(rec, "Rate limit exceeded", http.StatusTooManyRequests)
+			writeAuditEntry(rec, r, device.Name, start)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), deviceCtxKey, device))
+		next(rec, r)
+		writeAuditEntry(rec, r, device.Name, start)
+	}
+}
+
+func writeAuditEntry(rec *statusRecorder, r *http.Request, device string, start time.Time) {
+	if auditLogger == nil {
+		return
+	}
+	entry := auditEntry{
+		TS:        time.Now().UTC().Format(time.RFC3339),
+		Device:    device,
+		IP:        getClientIP(r),
+		Path:      r.URL.Path,
+		Status:    rec.status,
+		Bytes:     rec.bytes,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	auditLogger.Println(string(data))
+}
+
+// openAuditLog opens the JSONL audit log file under dir, separate from the
+// free-form operational log.
+func openAuditLog(dir string) (*log.Logger, *os.File, error) {
+	path := dir + string(os.PathSeparator) + "airclip-audit.jsonl"
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return log.New(f, "", 0), f, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHashTokenVerifyTokenRoundTrip(t *testing.T) {
+	token := "s3cr3t-device-token"
+
+	hash, err := hashToken(token)
+	if err != nil {
+		t.Fatalf("hashToken returned error: %v", err)
+	}
+
+	if !verifyToken(token, hash) {
+		t.Error("verifyToken rejected the token that produced the hash")
+	}
+}
+
+func TestVerifyTokenRejectsWrongToken(t *testing.T) {
+	hash, err := hashToken("correct-token")
+	if err != nil {
+		t.Fatalf("hashToken returned error: %v", err)
+	}
+
+	if verifyToken("wrong-token", hash) {
+		t.Error("verifyToken accepted a token that doesn't match the hash")
+	}
+}
+
+func TestVerifyTokenRejectsMalformedHash(t *testing.T) {
+	cases := []string{"", "no-separator", "bad-base64$also-bad-base64"}
+	for _, encoded := range cases {
+		if verifyToken("anything", encoded) {
+			t.Errorf("verifyToken accepted malformed hash %q", encoded)
+		}
+	}
+}
+
+func TestDeviceCredAllowsIP(t *testing.T) {
+	d := deviceCred{AllowedCIDRs: []string{"10.0.0.0/8"}}
+
+	if !d.allowsIP(mustParseIP(t, "10.1.2.3")) {
+		t.Error("allowsIP rejected an IP within the configured CIDR")
+	}
+	if d.allowsIP(mustParseIP(t, "192.168.1.1")) {
+		t.Error("allowsIP accepted an IP outside the configured CIDR")
+	}
+}
+
+func TestDeviceCredAllowsIPNoRestriction(t *testing.T) {
+	d := deviceCred{}
+	if !d.allowsIP(mustParseIP(t, "203.0.113.5")) {
+		t.Error("allowsIP with no allowed_cidrs should accept any IP")
+	}
+}
+
+func TestCredentialStoreAuthenticateNoAuthFile(t *testing.T) {
+	cs := &credentialStore{}
+
+	r := httptest.NewRequest(http.MethodGet, "/history", nil)
+	if _, ok := cs.authenticate(r); !ok {
+		t.Error("authenticate should accept every request when no --auth-file is configured")
+	}
+}
+
+func TestCredentialStoreAuthenticate(t *testing.T) {
+	hash, err := hashToken("good-token")
+	if err != nil {
+		t.Fatalf("hashToken returned error: %v", err)
+	}
+
+	cs := &credentialStore{
+		path: "/tmp/airclip-auth-test.json",
+		devices: []deviceCred{
+			{Name: "phone", TokenHash: hash, AllowedCIDRs: []string{"10.0.0.0/8"}},
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/history", nil)
+	r.Header.Set("Authorization", "Bearer good-token")
+	r.RemoteAddr = "10.0.0.5:54321"
+	device, ok := cs.authenticate(r)
+	if !ok || device.Name != "phone" {
+		t.Errorf("authenticate rejected a valid token from an allowed IP, got device=%+v ok=%v", device, ok)
+	}
+}
+
+func TestCredentialStoreAuthenticateRejectsBadToken(t *testing.T) {
+	hash, err := hashToken("good-token")
+	if err != nil {
+		t.Fatalf("hashToken returned error: %v", err)
+	}
+
+	cs := &credentialStore{
+		path:    "/tmp/airclip-auth-test.json",
+		devices: []deviceCred{{Name: "phone", TokenHash: hash}},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/history", nil)
+	r.Header.Set("Authorization", "Bearer wrong-token")
+	r.RemoteAddr = "10.0.0.5:54321"
+	if _, ok := cs.authenticate(r); ok {
+		t.Error("authenticate accepted a token that doesn't match any device")
+	}
+}
+
+func TestCredentialStoreAuthenticateRejectsDisallowedIP(t *testing.T) {
+	hash, err := hashToken("good-token")
+	if err != nil {
+		t.Fatalf("hashToken returned error: %v", err)
+	}
+
+	cs := &credentialStore{
+		path: "/tmp/airclip-auth-test.json",
+		devices: []deviceCred{
+			{Name: "phone", TokenHash: hash, AllowedCIDRs: []string{"10.0.0.0/8"}},
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/history", nil)
+	r.Header.Set("Authorization", "Bearer good-token")
+	r.RemoteAddr = "203.0.113.5:54321"
+	if _, ok := cs.authenticate(r); ok {
+		t.Error("authenticate accepted a valid token from an IP outside allowed_cidrs")
+	}
+}
+
+func mustParseIP(t *testing.T, s string) net.IP {
+	t.Helper()
+	ip := net.ParseIP(s)
+	if ip == nil {
+		t.Fatalf("invalid test IP %q", s)
+	}
+	return ip
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Clipboarder reads and writes the system clipboard.
+type Clipboarder interface {
+	// Copy writes text to the clipboard, replacing its current contents.
+	Copy(text string) error
+	// Read returns the current clipboard contents.
+	Read() (string, error)
+	// Name identifies the backend for logging purposes.
+	Name() string
+}
+
+// Notifier displays a desktop notification.
+type Notifier interface {
+	// Notify shows a notification with the given title and message.
+	Notify(title, message string) error
+	// Name identifies the backend for logging purposes.
+	Name() string
+}
+
+// lookPath reports whether name is an executable found on PATH.
+func lookPath(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// --- Clipboard backends ---
+
+type xclipClipboard struct{ bin string }
+
+func (c *xclipClipboard) Name() string { return c.bin }
+
+func (c *xclipClipboard) Copy(text string) error {
+	cmd := exec.Command(c.bin, "-selection", "clipboard")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (c *xclipClipboard) Read() (string, error) {
+	out, err := exec.Command(c.bin, "-selection", "clipboard", "-o").Output()
+	return string(out), err
+}
+
+type wlClipboard struct{}
+
+func (c *wlClipboard) Name() string { return "wl-copy" }
+
+func (c *wlClipboard) Copy(text string) error {
+	cmd := exec.Command("wl-copy")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (c *wlClipboard) Read() (string, error) {
+	out, err := exec.Command("wl-paste", "--no-newline").Output()
+	return string(out), err
+}
+
+type pbClipboard struct{}
+
+func (c *pbClipboard) Name() string { return "pbcopy" }
+
+func (c *pbClipboard) Copy(text string) error {
+	cmd := exec.Command("pbcopy")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (c *pbClipboard) Read() (string, error) {
+	out, err := exec.Command("pbpaste").Output()
+	return string(out), err
+}
+
+type winClipboard struct{}
+
+func (c *winClipboard) Name() string { return "clip.exe" }
+
+func (c *winClipboard) Copy(text string) error {
+	cmd := exec.Command("clip.exe")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (c *winClipboard) Read() (string, error) {
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-Command", "Get-Clipboard").Output()
+	return string(out), err
+}
+
+// --- Notifier backends ---
+
+type notifySendNotifier struct{ appName string }
+
+func (n *notifySendNotifier) Name() string { return "notify-send" }
+
+func (n *notifySendNotifier) Notify(title, message string) error {
+	cmd := exec.Command("notify-send",
+		fmt.Sprintf("--app-name=%s", n.appName),
+		"--icon=dialog-information",
+		title,
+		message,
+	)
+	return cmd.Run()
+}
+
+type osascriptNotifier struct{}
+
+func (n *osascriptNotifier) Name() string { return "osascript" }
+
+func (n *osascriptNotifier) Notify(title, message string) error {
+	script := fmt.Sprintf("display notification %q with title %q", message, title)
+	cmd := exec.Command("osascript", "-e", script)
+	return cmd.Run()
+}
+
+type terminalNotifierNotifier struct{}
+
+func (n *terminalNotifierNotifier) Name() string { return "terminal-notifier" }
+
+func (n *terminalNotifierNotifier) Notify(title, message string) error {
+	cmd := exec.Command("terminal-notifier", "-title", title, "-message", message)
+	return cmd.Run()
+}
+
+type burntToastNotifier struct{}
+
+func (n *burntToastNotifier) Name() string { return "BurntToast" }
+
+func (n *burntToastNotifier) Notify(title, message string) error {
+	script := fmt.Sprintf("New-BurntToastNotification -Text %s, %s", psQuote(title), psQuote(message))
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-Command", script)
+	return cmd.Run()
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// detectClipboardBackend picks a Clipboarder for the current platform, honoring
+// an explicit --clipboard-backend override. It fails if the required binary is
+// not found on PATH.
+func detectClipboardBackend(override string) (Clipboarder, error) {
+	if override != "" {
+		return newClipboardBackend(override)
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return newClipboardBackend("windows")
+	case "darwin":
+		return newClipboardBackend("macos")
+	default: // linux and other unix-likes
+		if isWayland() {
+			return newClipboardBackend("wayland")
+		}
+		return newClipboardBackend("x11")
+	}
+}
+
+func newClipboardBackend(name string) (Clipboarder, error) {
+	switch name {
+	case "x11":
+		if lookPath("xclip") {
+			return &xclipClipboard{bin: "xclip"}, nil
+		}
+		if lookPath("xsel") {
+			return &xselClipboard{}, nil
+		}
+		return nil, fmt.Errorf("x11 clipboard backend requires xclip or xsel on PATH")
+	case "wayland":
+		if !lookPath("wl-copy") {
+			return nil, fmt.Errorf("wayland clipboard backend requires wl-copy on PATH")
+		}
+		return &wlClipboard{}, nil
+	case "macos":
+		if !lookPath("pbcopy") {
+			return nil, fmt.Errorf("macos clipboard backend requires pbcopy on PATH")
+		}
+		return &pbClipboard{}, nil
+	case "windows":
+		if !lookPath("clip.exe") {
+			return nil, fmt.Errorf("windows clipboard backend requires clip.exe on PATH")
+		}
+		return &winClipboard{}, nil
+	default:
+		return nil, fmt.Errorf("unknown clipboard backend %q", name)
+	}
+}
+
+type xselClipboard struct{}
+
+func (c *xselClipboard) Name() string { return "xsel" }
+
+func (c *xselClipboard) Copy(text string) error {
+	cmd := exec.Command("xsel", "--clipboard", "--input")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (c *xselClipboard) Read() (string, error) {
+	out, err := exec.Command("xsel", "--clipboard", "--output").Output()
+	return string(out), err
+}
+
+// detectNotifyBackend picks a Notifier for the current platform, honoring an
+// explicit --notify-backend override.
+func detectNotifyBackend(override string) (Notifier, error) {
+	if override != "" {
+		return newNotifyBackend(override)
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return newNotifyBackend("windows")
+	case "darwin":
+		return newNotifyBackend("macos")
+	default: // linux: notify-send works on both X11 and Wayland (via mako or similar)
+		return newNotifyBackend("libnotify")
+	}
+}
+
+func newNotifyBackend(name string) (Notifier, error) {
+	switch name {
+	case "libnotify":
+		if !lookPath("notify-send") {
+			return nil, fmt.Errorf("libnotify backend requires notify-send on PATH")
+		}
+		return &notifySendNotifier{appName: "Airclip"}, nil
+	case "macos":
+		if lookPath("terminal-notifier") {
+			return &terminalNotifierNotifier{}, nil
+		}
+		if lookPath("osascript") {
+			return &osascriptNotifier{}, nil
+		}
+		return nil, fmt.Errorf("macos notify backend requires terminal-notifier or osascript on PATH")
+	case "windows":
+		if !lookPath("powershell.exe") {
+			return nil, fmt.Errorf("windows notify backend requires powershell.exe (BurntToast) on PATH")
+		}
+		return &burntToastNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown notify backend %q", name)
+	}
+}
+
+// isWayland reports whether we appear to be running under a Wayland session.
+func isWayland() bool {
+	return os.Getenv("WAYLAND_DISPLAY") != ""
+}
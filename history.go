@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	historySize   = flag.Int("history-size", 100, "Number of clipboard entries to keep in history")
+	historyDBPath = flag.String("history-db", "", "Optional path to a BoltDB file for persisting clipboard history across restarts")
+
+	redactPatterns stringListFlag
+)
+
+func init() {
+	flag.Var(&redactPatterns, "redact-pattern", "Regex pattern to redact from history entries; may be given multiple times")
+}
+
+// stringListFlag collects repeated occurrences of a flag into a slice.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+var historyBucket = []byte("history")
+
+// HistoryEntry is one clipboard push recorded in history.
+type HistoryEntry struct {
+	ID          uint64 `json:"id"`
+	TS          int64  `json:"ts"`
+	SourceIP    string `json:"source_ip"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+	SHA256      string `json:"sha256"`
+}
+
+// History is a bounded, optionally persistent log of clipboard pushes.
+type History struct {
+	mu       sync.Mutex
+	entries  []HistoryEntry
+	cap      int
+	nextID   uint64
+	lastHash string
+	redact   []*regexp.Regexp
+	db       *bbolt.DB
+}
+
+// newHistory builds a History capped at size entries, optionally backed by a
+// BoltDB file at dbPath, and redacting any content matching a pattern.
+func newHistory(size int, dbPath string, patterns []string) (*History, error) {
+	// IDs start at 1 so that the default "since=0" query parameter means
+	// "from the beginning" without excluding the first entry.
+	h := &History{cap: size, nextID: 1}
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		h.redact = append(h.redact, re)
+	}
+
+	if dbPath != "" {
+		db, err := bbolt.Open(dbPath, 0600, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open history db: %w", err)
+		}
+		if err := db.Update(func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(historyBucket)
+			return err
+		}); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to init history db: %w", err)
+		}
+		h.db = db
+
+		if err := h.loadFromDB(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+func (h *History) loadFromDB() error {
+	return h.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			h.entries = append(h.entries, entry)
+			if entry.ID >= h.nextID {
+				h.nextID = entry.ID + 1
+			}
+		}
+		if len(h.entries) > h.cap {
+			h.entries = h.entries[len(h.entries)-h.cap:]
+		}
+		if len(h.entries) > 0 {
+			h.lastHash = h.entries[len(h.entries)-1].SHA256
+		}
+		return nil
+	})
+}
+
+// Close releases the underlying database, if one is open.
+func (h *History) Close() error {
+	if h.db == nil {
+		return nil
+	}
+	return h.db.Close()
+}
+
+// redactContent replaces any substring matched by a configured redact
+// pattern so secrets (e.g. passwords pasted from a manager) never persist.
+func (h *History) redactContent(content string) string {
+	for _, re := range h.redact {
+		content = re.ReplaceAllString(content, "[redacted]")
+	}
+	return content
+}
+
+// Append records a clipboard push, deduplicating consecutive identical
+// content by sha256. Returns the stored entry, or ok=false if it was a
+// duplicate of the most recent entry and was skipped.
+func (h *History) Append(sourceIP, contentType, content string) (HistoryEntry, bool) {
+	sum := sha256.Sum256([]byte(content))
+	hash := fmt.Sprintf("%x", sum)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if hash == h.lastHash {
+		return HistoryEntry{}, false
+	}
+	h.lastHash = hash
+
+	entry := HistoryEntry{
+		ID:          h.nextID,
+		TS:          time.Now().Unix(),
+		SourceIP:    sourceIP,
+		ContentType: contentType,
+		Content:     h.redactContent(content),
+		SHA256:      hash,
+	}
+	h.nextID++
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.cap {
+		h.entries = h.entries[len(h.entries)-h.cap:]
+	}
+
+	if h.db != nil {
+		h.persist(entry)
+	}
+
+	return entry, true
+}
+
+func (h *History) persist(entry HistoryEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := h.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(historyBucket).Put(historyKey(entry.ID), data)
+	}); err != nil {
+		logf("Failed to persist history entry %d: %v\n", entry.ID, err)
+	}
+}
+
+func historyKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// List returns up to limit entries with ID greater than since, newest last.
+// limit <= 0 means no limit.
+func (h *History) List(since uint64, limit int) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var out []HistoryEntry
+	for _, e := range h.entries {
+		if e.ID > since {
+			out = append(out, e)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// Get returns the entry with the given ID, if present.
+func (h *History) Get(id uint64) (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range h.entries {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// Delete removes the entry with the given ID, if present, from memory and
+// the persistent store.
+func (h *History) Delete(id uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, e := range h.entries {
+		if e.ID == id {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			if h.db != nil {
+				h.db.Update(func(tx *bbolt.Tx) error {
+					return tx.Bucket(historyBucket).Delete(historyKey(id))
+				})
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// history is the process-wide clipboard history store, set up in main().
+var history *History
+
+// handleHistoryList serves GET /history?limit=&since=
+func handleHistoryList(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, _ = strconv.Atoi(v)
+	}
+	var since uint64
+	if v := r.URL.Query().Get("since"); v != "" {
+		since, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history.List(since, limit))
+}
+
+// handleHistoryEntry serves GET/DELETE /history/{id}
+func handleHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseHistoryID(r.URL.Path, "/history/")
+	if !ok {
+		http.Error(w, "Invalid history ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		entry, found := history.Get(id)
+		if !found {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+	case http.MethodDelete:
+		if !history.Delete(id) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHistoryRestore serves POST /history/{id}/restore: re-copies the
+// entry to the clipboard and fires a notification, same as a fresh /notify.
+func handleHistoryRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseHistoryID(r.URL.Path, "/history/")
+	if !ok {
+		http.Error(w, "Invalid history ID", http.StatusBadRequest)
+		return
+	}
+
+	entry, found := history.Get(id)
+	if !found {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if err := processNotification(entry.Content); err != nil {
+		logf("Error restoring history entry %d: %v\n", id, err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Restored\n"))
+}
+
+// handleHistoryDispatch routes /history/{id} and /history/{id}/restore
+// requests, since the standard mux only matches path prefixes.
+func handleHistoryDispatch(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/restore") {
+		handleHistoryRestore(w, r)
+		return
+	}
+	handleHistoryEntry(w, r)
+}
+
+// parseHistoryID extracts the numeric ID from a /history/{id} or
+// /history/{id}/restore path.
+func parseHistoryID(path, prefix string) (uint64, bool) {
+	rest := strings.TrimPrefix(path, prefix)
+	rest = strings.TrimSuffix(rest, "/restore")
+	id, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -10,7 +10,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
@@ -22,25 +21,45 @@ import (
 // Global logger
 var logger *log.Logger
 
-// To test: curl -s -X POST -H "Authorization: Bearer local-use-only" -d "testfromcurl" http://localhost:9123/notify
+// To test: curl -s -X POST -H "Authorization: Bearer <device-token>" -d "testfromcurl" http://localhost:9123/notify
 
 const (
 	// exitCodeErr is the code to return in case of any error in the program
 	exitCodeErr = 1
 	// exitCodeInterrupt is the code to return in case of an interrupt signal
 	exitCodeInterrupt = 2
+
+	// appVersion is advertised via mDNS TXT records and the /discover endpoint
+	appVersion = "0.1.0"
 )
 
 var (
 	// Default to port 9123, which is likely not used by other common services
 	port       = flag.String("port", "9123", "HTTP port to listen on")
 	listenAddr = flag.String("listen", "0.0.0.0", "IP address to listen on (use local IPs only)")
-	authToken  = flag.String("token", "local-use-only", "Simple authentication token")
 	localOnly  = flag.Bool("local-only", true, "Restrict to local network connections only")
 	allowCORS  = flag.Bool("cors", true, "Enable CORS for cross-origin requests")
 	logDir     = flag.String("logdir", "/tmp", "Directory to store log files")
+
+	clipboardBackendFlag = flag.String("clipboard-backend", "", "Clipboard backend to use: x11, wayland, macos, windows (default: auto-detect)")
+	notifyBackendFlag    = flag.String("notify-backend", "", "Notification backend to use: libnotify, macos, windows (default: auto-detect)")
+
+	syncHistorySize = flag.Int("history", 20, "Number of recent clipboard entries to replay to /sync clients on connect")
 )
 
+// Selected at startup by detectClipboardBackend/detectNotifyBackend.
+var (
+	clipboarder Clipboarder
+	notifier    Notifier
+)
+
+// hub fans out clipboard updates to /sync subscribers; sized by --history.
+var hub *syncHub
+
+// clipboardPollInterval is how often the clipboard watcher polls the backend
+// for changes to publish over /sync.
+const clipboardPollInterval = 1 * time.Second
+
 func main() {
 	flag.Parse()
 
@@ -55,6 +74,19 @@ func main() {
 		cancel(nil)
 	}()
 
+	// Reload the credentials file on SIGHUP without restarting
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if err := auth.reload(); err != nil {
+				logf("Failed to reload auth file: %v\n", err)
+				continue
+			}
+			logf("Reloaded auth file\n")
+		}
+	}()
+
 	// Setup logging with timestamp in filename
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
 	logFilePath := filepath.Join(*logDir, fmt.Sprintf("notification-server_%s.log", timestamp))
@@ -76,8 +108,55 @@ func main() {
 		logf("WARNING: Running with local-only protection disabled. This is not recommended.\n")
 	}
 
-	// Print local IP addresses for user reference
-	printLocalIPs()
+	// Pick the clipboard and notification backends for this platform
+	clipboarder, err = detectClipboardBackend(*clipboardBackendFlag)
+	if err != nil {
+		logf("Failed to select clipboard backend: %v\n", err)
+		os.Exit(exitCodeErr)
+	}
+	notifier, err = detectNotifyBackend(*notifyBackendFlag)
+	if err != nil {
+		logf("Failed to select notification backend: %v\n", err)
+		os.Exit(exitCodeErr)
+	}
+	logf("Using clipboard backend: %s, notification backend: %s\n", clipboarder.Name(), notifier.Name())
+
+	hub = newSyncHub(*syncHistorySize)
+
+	var authErr error
+	auth, authErr = newCredentialStore(*authFile, *rateFlag)
+	if authErr != nil {
+		logf("Failed to load auth file: %v\n", authErr)
+		os.Exit(exitCodeErr)
+	}
+	if *authFile == "" {
+		logf("WARNING: No --auth-file configured, all requests are accepted without authentication.\n")
+	}
+
+	auditLog, auditFile, err := openAuditLog(*logDir)
+	if err != nil {
+		logf("Failed to open audit log: %v\n", err)
+		os.Exit(exitCodeErr)
+	}
+	auditLogger = auditLog
+	defer auditFile.Close()
+
+	var histErr error
+	history, histErr = newHistory(*historySize, *historyDBPath, redactPatterns)
+	if histErr != nil {
+		logf("Failed to set up clipboard history: %v\n", histErr)
+		os.Exit(exitCodeErr)
+	}
+	defer history.Close()
+
+	// Advertise via mDNS/Bonjour so the iOS shortcut can use zero-config discovery
+	if *mdnsEnabled {
+		if err := startMDNS(); err != nil {
+			logf("Failed to start mDNS advertisement: %v\n", err)
+			os.Exit(exitCodeErr)
+		}
+		defer stopMDNS()
+	}
 
 	// Handle signals in a goroutine
 	go func() {
@@ -104,7 +183,12 @@ func main() {
 func run(ctx context.Context, cancel context.CancelCauseFunc) error {
 	// Set up HTTP server
 	mux := http.NewServeMux()
-	mux.HandleFunc("/notify", handleNotification)
+	mux.HandleFunc("/notify", withAudit(handleNotification))
+	mux.HandleFunc("/sync", withAudit(handleSync))
+	mux.HandleFunc("/history", withAudit(handleHistoryList))
+	mux.HandleFunc("/history/", withAudit(handleHistoryDispatch))
+	mux.HandleFunc("/admin/tokens", withAudit(handleAdminTokens))
+	mux.HandleFunc("/discover", handleDiscover)
 	mux.HandleFunc("/health", handleHealthCheck)
 
 	// Create server with reasonable timeouts
@@ -120,6 +204,13 @@ func run(ctx context.Context, cancel context.CancelCauseFunc) error {
 	// Use WaitGroup to coordinate shutdown
 	var wg sync.WaitGroup
 
+	// Watch the clipboard for local changes to publish over /sync
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watchClipboard(ctx, clipboardPollInterval)
+	}()
+
 	// Start server in a goroutine
 	wg.Add(1)
 	go func() {
@@ -132,6 +223,35 @@ func run(ctx context.Context, cancel context.CancelCauseFunc) error {
 		}
 	}()
 
+	// Optionally also serve on the tailnet via an embedded tsnet node
+	var tsnetServer *http.Server
+	if *tsnetEnabled {
+		ln, err := startTsnet(ctx)
+		if err != nil {
+			return fmt.Errorf("tsnet setup failed: %w", err)
+		}
+		tsnetServer = &http.Server{
+			Handler:      mux,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logf("Starting notification server on tailnet (hostname %s)\n", *tsnetHostname)
+
+			if err := tsnetServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logf("tsnet HTTP server failed: %v\n", err)
+				cancel(err)
+			}
+		}()
+	}
+
+	// Print local IP addresses (and tailnet name, if any) for user reference
+	printLocalIPs()
+
 	// Wait for context cancellation
 	<-ctx.Done()
 	logf("Context done: %v\n", context.Cause(ctx))
@@ -145,6 +265,10 @@ func run(ctx context.Context, cancel context.CancelCauseFunc) error {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("server shutdown failed: %w", err)
 	}
+	if tsnetServer != nil {
+		tsnetServer.Shutdown(shutdownCtx)
+		closeTsnet()
+	}
 
 	// Wait for server goroutine to finish
 	wg.Wait()
@@ -159,16 +283,6 @@ func logf(format string, v ...any) {
 
 // handleNotification processes notification requests
 func handleNotification(w http.ResponseWriter, r *http.Request) {
-	// Check if request is from local network if local-only is enabled
-	if *localOnly {
-		clientIP := getClientIP(r)
-		if !isLocalIP(clientIP) {
-			logf("Rejected non-local request from %s\n", clientIP)
-			http.Error(w, "This service is restricted to local network use only", http.StatusForbidden)
-			return
-		}
-	}
-
 	// Enable CORS if requested
 	if *allowCORS {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -187,16 +301,6 @@ func handleNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check authentication if a token is set
-	if *authToken != "" {
-		providedToken := r.Header.Get("Authorization")
-		if providedToken != "Bearer "+*authToken {
-			logf("Unauthorized access attempt from %s\n", getClientIP(r))
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-	}
-
 	// Read the message from the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -212,7 +316,8 @@ func handleNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logf("Received notification request from %s: %d chars\n", getClientIP(r), len(message))
+	device := deviceFromContext(r)
+	logf("Received notification request from %s (device=%s): %d chars\n", getClientIP(r), device, len(message))
 
 	// Send notification and copy to clipboard
 	if err := processNotification(message); err != nil {
@@ -221,6 +326,14 @@ func handleNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	clientIP := getClientIP(r)
+	hub.publish(syncFrame{
+		TS:      time.Now().Unix(),
+		Source:  clientIP,
+		Content: message,
+	})
+	history.Append(clientIP, r.Header.Get("Content-Type"), message)
+
 	// Return success response
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
@@ -237,22 +350,14 @@ func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Notification server is running\n"))
 }
 
-// processNotification sends a desktop notification and copies text to clipboard
+// processNotification copies text to the clipboard and sends a desktop
+// notification using the backends selected at startup.
 func processNotification(message string) error {
-	// First copy to clipboard using xclip
-	clipCmd := exec.Command("bash", "-c", fmt.Sprintf("echo -n %q | xclip -selection clipboard", message))
-	if err := clipCmd.Run(); err != nil {
+	if err := clipboarder.Copy(message); err != nil {
 		return fmt.Errorf("failed to copy to clipboard: %v", err)
 	}
 
-	// Then send notification
-	notifyCmd := exec.Command("notify-send",
-		"--app-name=NotificationServer",
-		"--icon=dialog-information",
-		"Text copied to clipboard from Airclip",
-	)
-
-	return notifyCmd.Run()
+	return notifier.Notify("Airclip", "Text copied to clipboard from Airclip")
 }
 
 // Helper functions for local network validation
@@ -311,6 +416,21 @@ func isLocalIP(ipStr string) bool {
 	return false
 }
 
+// requestAllowed reports whether r may reach endpoints gated by --local-only:
+// either its source IP is on the LAN, or (when --tsnet is enabled) it's a
+// tailnet peer. tsnet runs in addition to the LAN socket, not instead of it,
+// so the two checks are additive rather than one overriding the other.
+func requestAllowed(r *http.Request) bool {
+	if !*localOnly {
+		return true
+	}
+	allowed := isLocalIP(getClientIP(r))
+	if *tsnetEnabled {
+		allowed = allowed || isTailnetPeer(r.Context(), r.RemoteAddr)
+	}
+	return allowed
+}
+
 // printLocalIPs prints all local IP addresses for the user to configure their iOS device
 func printLocalIPs() {
 	interfaces, err := net.Interfaces()
@@ -322,6 +442,10 @@ func printLocalIPs() {
 	logf("Available local IP addresses to use in your iOS shortcut:\n")
 	logf("----------------------------------------------------------\n")
 
+	if name := tsnetMagicDNSName(); name != "" {
+		logf("Tailnet:   %-10s  URL: http://%s:%s/notify\n", *tsnetHostname, name, *port)
+	}
+
 	for _, iface := range interfaces {
 		// Skip loopback and inactive interfaces
 		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/mdns"
+)
+
+var (
+	mdnsEnabled = flag.Bool("mdns", true, "Advertise this server via mDNS/Bonjour as _airclip._tcp.local.")
+	mdnsName    = flag.String("mdns-name", "airclip", "Instance name to advertise via mDNS")
+)
+
+const mdnsServiceType = "_airclip._tcp"
+
+// mdnsServer is the running mDNS advertiser, set by startMDNS.
+var mdnsServer *mdns.Server
+
+// discoverInfo is returned by GET /discover so clients that can't do mDNS
+// lookups (e.g. the iOS Shortcuts app) can still find this server's features.
+type discoverInfo struct {
+	Version  string `json:"version"`
+	Auth     string `json:"auth"`
+	Features string `json:"features"`
+	Port     string `json:"port"`
+}
+
+// startMDNS advertises this server as _airclip._tcp.local. with TXT records
+// describing version, auth requirement, and supported features.
+func startMDNS() error {
+	portNum, err := strconv.Atoi(*port)
+	if err != nil {
+		return fmt.Errorf("invalid port for mDNS advertisement: %w", err)
+	}
+
+	txt := []string{
+		"version=" + appVersion,
+		"auth=" + authTXTValue(),
+		"features=notify,sync,history",
+	}
+
+	service, err := mdns.NewMDNSService(*mdnsName, mdnsServiceType, "", "", portNum, nil, txt)
+	if err != nil {
+		return fmt.Errorf("failed to build mDNS service: %w", err)
+	}
+
+	mdnsServer, err = mdns.NewServer(&mdns.Config{Zone: service})
+	if err != nil {
+		return fmt.Errorf("failed to start mDNS server: %w", err)
+	}
+
+	logf("Advertising via mDNS as %s.%s.local. on port %s\n", *mdnsName, mdnsServiceType, *port)
+	return nil
+}
+
+// stopMDNS deregisters the mDNS advertisement, if one was started.
+func stopMDNS() {
+	if mdnsServer != nil {
+		mdnsServer.Shutdown()
+	}
+}
+
+func authTXTValue() string {
+	if *authFile == "" {
+		return "none"
+	}
+	return "required"
+}
+
+// handleDiscover returns the same information advertised over mDNS, for
+// clients that resolve the server by hitting a known host directly instead
+// of doing a service lookup.
+func handleDiscover(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discoverInfo{
+		Version:  appVersion,
+		Auth:     authTXTValue(),
+		Features: "notify,sync,history",
+		Port:     *port,
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// syncFrame is the JSON message exchanged over the /sync WebSocket: a
+// clipboard update either broadcast to subscribers or pushed by a client.
+type syncFrame struct {
+	TS      int64  `json:"ts"`
+	Source  string `json:"source"`
+	Content string `json:"content"`
+}
+
+// syncHub fans out clipboard updates to connected /sync subscribers and
+// keeps a bounded history of recent entries for newly connected clients.
+type syncHub struct {
+	mu         sync.Mutex
+	subs       sync.Map // map[*syncSub]struct{}
+	history    []syncFrame
+	historyCap int
+	lastHash   [32]byte
+}
+
+// syncSub is one /sync subscriber's channel, guarded by its own mutex so a
+// send in publish can never race a close in unsubscribe.
+type syncSub struct {
+	mu     sync.Mutex
+	ch     chan syncFrame
+	closed bool
+}
+
+func (s *syncSub) send(frame syncFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- frame:
+	default: // subscriber too slow, drop the frame rather than block
+	}
+}
+
+func (s *syncSub) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+}
+
+func newSyncHub(historyCap int) *syncHub {
+	return &syncHub{historyCap: historyCap}
+}
+
+// subscribe registers a new subscriber channel and returns it along with an
+// unsubscribe func that must be called when the connection closes.
+func (h *syncHub) subscribe() (chan syncFrame, func()) {
+	sub := &syncSub{ch: make(chan syncFrame, 16)}
+	h.subs.Store(sub, struct{}{})
+	return sub.ch, func() {
+		h.subs.Delete(sub)
+		sub.close()
+	}
+}
+
+// recent returns a copy of the current history buffer, oldest first.
+func (h *syncHub) recent() []syncFrame {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]syncFrame, len(h.history))
+	copy(out, h.history)
+	return out
+}
+
+// publish broadcasts a frame to all subscribers and appends it to history,
+// deduplicating consecutive identical content by hash.
+func (h *syncHub) publish(frame syncFrame) {
+	hash := sha256.Sum256([]byte(frame.Content))
+
+	h.mu.Lock()
+	if hash == h.lastHash {
+		h.mu.Unlock()
+		return
+	}
+	h.lastHash = hash
+	h.history = append(h.history, frame)
+	if len(h.history) > h.historyCap {
+		h.history = h.history[len(h.history)-h.historyCap:]
+	}
+	h.mu.Unlock()
+
+	h.subs.Range(func(key, _ any) bool {
+		key.(*syncSub).send(frame)
+		return true
+	})
+}
+
+var syncUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// handleSync upgrades authenticated requests to a WebSocket connection that
+// streams clipboard updates and accepts inbound pushes from the client,
+// mirroring the supervisor's wsEvents long-lived broadcast pattern. Checked
+// again here (on top of withAudit) because a WebSocket upgrade hands the
+// connection off to gorilla/websocket, so this is the last point before the
+// request leaves the normal handler chain.
+func handleSync(w http.ResponseWriter, r *http.Request) {
+	if !requestAllowed(r) {
+		logf("Rejected non-local /sync connection from %s\n", getClientIP(r))
+		http.Error(w, "This service is restricted to local network use only", http.StatusForbidden)
+		return
+	}
+	if _, ok := auth.authenticate(r); !ok {
+		logf("Unauthorized /sync connection attempt from %s\n", getClientIP(r))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := syncUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logf("Failed to upgrade /sync connection from %s: %v\n", getClientIP(r), err)
+		return
+	}
+	defer conn.Close()
+
+	clientIP := getClientIP(r)
+	logf("Sync client connected from %s\n", clientIP)
+
+	for _, frame := range hub.recent() {
+		if err := conn.WriteJSON(frame); err != nil {
+			logf("Failed to send history to %s: %v\n", clientIP, err)
+			return
+		}
+	}
+
+	updates, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var frame syncFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			frame.TS = time.Now().Unix()
+			frame.Source = clientIP
+
+			if err := clipboarder.Copy(frame.Content); err != nil {
+				logf("Failed to apply inbound sync frame from %s: %v\n", clientIP, err)
+				continue
+			}
+			hub.publish(frame)
+			history.Append(clientIP, "text/plain", frame.Content)
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			logf("Sync client disconnected: %s\n", clientIP)
+			return
+		case frame, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(frame); err != nil {
+				logf("Failed to write sync frame to %s: %v\n", clientIP, err)
+				return
+			}
+		}
+	}
+}
+
+// watchClipboard polls the active Clipboarder for changes and publishes them
+// to the sync hub until ctx is canceled.
+func watchClipboard(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			content, err := clipboarder.Read()
+			if err != nil {
+				continue
+			}
+			if content == "" {
+				continue
+			}
+			hub.publish(syncFrame{
+				TS:      time.Now().Unix(),
+				Source:  "local",
+				Content: content,
+			})
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tailscale.com/tsnet"
+)
+
+var (
+	tsnetEnabled     = flag.Bool("tsnet", false, "Listen on the tailnet via an embedded Tailscale node, instead of (or in addition to) the LAN socket")
+	tsnetHostname    = flag.String("tsnet-hostname", "airclip", "Hostname to advertise on the tailnet")
+	tsnetAuthKeyFile = flag.String("tsnet-authkey-file", "", "Path to a file containing a Tailscale auth key for unattended login")
+	tsnetAllowedTag  = flag.String("tsnet-allowed-tag", "", "If set, only accept tailnet peers carrying this tag (e.g. tag:airclip)")
+)
+
+// tsServer is the running tsnet node, set by startTsnet when --tsnet is enabled.
+var tsServer *tsnet.Server
+
+// startTsnet brings up an embedded Tailscale node and returns a listener on
+// it for the server to serve the same mux on. The caller is responsible for
+// closing the returned listener; tsServer itself is closed via closeTsnet.
+func startTsnet(ctx context.Context) (net.Listener, error) {
+	tsServer = &tsnet.Server{
+		Hostname: *tsnetHostname,
+		Dir:      filepath.Join(*logDir, "tsnet-"+*tsnetHostname),
+	}
+
+	if *tsnetAuthKeyFile != "" {
+		authKey, err := os.ReadFile(*tsnetAuthKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tsnet auth key file: %w", err)
+		}
+		tsServer.AuthKey = strings.TrimSpace(string(authKey))
+	}
+
+	if err := tsServer.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start tsnet node: %w", err)
+	}
+
+	ln, err := tsServer.Listen("tcp", ":"+*port)
+	if err != nil {
+		tsServer.Close()
+		return nil, fmt.Errorf("failed to listen on tailnet: %w", err)
+	}
+
+	if status, err := tsServer.Up(ctx); err == nil && status.Self != nil {
+		logf("Tailscale node up: %s\n", strings.TrimSuffix(status.Self.DNSName, "."))
+	}
+
+	return ln, nil
+}
+
+// closeTsnet shuts down the embedded Tailscale node, if one was started.
+func closeTsnet() {
+	if tsServer != nil {
+		tsServer.Close()
+	}
+}
+
+// tsnetMagicDNSName returns the tailnet MagicDNS name for the local node, or
+// "" if tsnet is not enabled or the node isn't up yet.
+func tsnetMagicDNSName() string {
+	if tsServer == nil {
+		return ""
+	}
+	lc, err := tsServer.LocalClient()
+	if err != nil {
+		return ""
+	}
+	st, err := lc.Status(context.Background())
+	if err != nil || st.Self == nil {
+		return ""
+	}
+	return strings.TrimSuffix(st.Self.DNSName, ".")
+}
+
+// isTailnetPeer reports whether remoteAddr belongs to a node on the tailnet,
+// consulting tsServer's LocalClient WhoIs and, if --tsnet-allowed-tag is set,
+// requiring the peer to carry that ACL tag.
+func isTailnetPeer(ctx context.Context, remoteAddr string) bool {
+	if tsServer == nil {
+		return false
+	}
+
+	lc, err := tsServer.LocalClient()
+	if err != nil {
+		return false
+	}
+
+	who, err := lc.WhoIs(ctx, remoteAddr)
+	if err != nil || who.Node == nil {
+		return false
+	}
+
+	if *tsnetAllowedTag == "" {
+		return true
+	}
+
+	for _, tag := range who.Node.Tags {
+		if tag == *tsnetAllowedTag {
+			return true
+		}
+	}
+	return false
+}